@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dnsprovider defines the interfaces that must be implemented by any
+// DNS provider that kops manages zones and records through.
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/kops/dnsprovider/pkg/dnsprovider/rrstype"
+)
+
+// Interface is an abstract, pluggable interface for DNS providers.
+type Interface interface {
+	// Zones returns the provider's Zones interface, or false if not supported.
+	Zones() (Zones, bool)
+}
+
+// Zones is an interface for managing DNS zones.
+type Zones interface {
+	// List returns the managed zones.
+	List() ([]Zone, error)
+	// Add creates and returns a new managed zone, instantiated via New().
+	Add(Zone) (Zone, error)
+	// Remove deletes a managed zone.
+	Remove(Zone) error
+	// New allocates a new Zone, which can then be passed to Add().
+	New(name string) (Zone, error)
+}
+
+// Zone is a DNS zone managed by a provider.
+type Zone interface {
+	// Name returns the fully qualified domain name of the zone, e.g "example.com".
+	Name() string
+	// ID returns the provider-specific identifier of the zone.
+	ID() string
+	// ResourceRecordSets returns the zone's ResourceRecordSets interface, or false if not supported.
+	ResourceRecordSets() (ResourceRecordSets, bool)
+}
+
+// ResourceRecordSets is an interface for managing DNS resource record sets within a zone.
+type ResourceRecordSets interface {
+	// List returns the resource record sets in the zone.
+	List() ([]ResourceRecordSet, error)
+	// Get returns the resource record sets matching name, or an empty slice if none exist.
+	Get(name string) ([]ResourceRecordSet, error)
+	// New allocates a new ResourceRecordSet, which can then be passed to a changeset's Add().
+	New(name string, rrdatas []string, ttl int64, rrsType rrstype.RrsType) ResourceRecordSet
+	// StartChangeset begins a new batch of changes to be applied via Apply.
+	StartChangeset() ResourceRecordChangeset
+	// Zone returns the parent zone.
+	Zone() Zone
+}
+
+// ResourceRecordSet is a single DNS resource record set.
+type ResourceRecordSet interface {
+	// Name returns the fully qualified domain name of the record, e.g "www.example.com".
+	Name() string
+	// Rrdatas returns the record values, e.g. ["1.2.3.4"].
+	Rrdatas() []string
+	// Ttl returns the time-to-live of the record, in seconds.
+	Ttl() int64
+	// Type returns the type of the record, e.g. rrstype.A.
+	Type() rrstype.RrsType
+}
+
+// ResourceRecordChangeset accumulates a set of changes to ResourceRecordSets,
+// to be applied together by Apply. Whether the whole set is applied
+// atomically depends on the provider.
+type ResourceRecordChangeset interface {
+	// Add queues the addition of a ResourceRecordSet.
+	Add(ResourceRecordSet) ResourceRecordChangeset
+	// Remove queues the removal of a ResourceRecordSet.
+	Remove(ResourceRecordSet) ResourceRecordChangeset
+	// Apply applies the accumulated changes to the provider.
+	Apply(ctx context.Context) error
+	// IsEmpty returns true if the changeset has no queued changes.
+	IsEmpty() bool
+	// ResourceRecordSets returns the ResourceRecordSets that this changeset will apply to.
+	ResourceRecordSets() ResourceRecordSets
+}
+
+// WaitingChangeset is an optional interface that a ResourceRecordChangeset
+// may implement if its provider can confirm when queued changes have fully
+// propagated, rather than merely been accepted.
+type WaitingChangeset interface {
+	// ApplyAndWait applies the changeset, like Apply, but does not return
+	// until the provider confirms the change has propagated or ctx is done.
+	ApplyAndWait(ctx context.Context) error
+}
+
+// Taggable is an optional interface that a Zone may implement if its
+// provider supports reading and writing tags on the underlying zone
+// resource. Providers that don't support tagging simply don't implement it;
+// callers should type-assert a Zone to Taggable before use.
+type Taggable interface {
+	// Tags returns the tags currently associated with the zone.
+	Tags(ctx context.Context) (map[string]string, error)
+	// SetTags replaces the zone's tags with the given set.
+	SetTags(ctx context.Context, tags map[string]string) error
+}
+
+// FilterZonesByTag returns the subset of zones whose tags contain key=value.
+// Zones whose provider doesn't implement Taggable are skipped.
+func FilterZonesByTag(ctx context.Context, zones []Zone, key, value string) ([]Zone, error) {
+	var result []Zone
+	for _, zone := range zones {
+		taggable, ok := zone.(Taggable)
+		if !ok {
+			continue
+		}
+		tags, err := taggable.Tags(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tags for zone %q: %v", zone.Name(), err)
+		}
+		if tags[key] == value {
+			result = append(result, zone)
+		}
+	}
+	return result, nil
+}
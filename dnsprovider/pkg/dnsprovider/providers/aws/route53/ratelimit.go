@@ -0,0 +1,230 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route53
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	smithy "github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// Defaults for the token-bucket rate limiter shared by every Zones and
+// ResourceRecordSets created from the same Interface, and for the backoff
+// applied to retried calls.
+const (
+	defaultRateLimit = 5
+	defaultRateBurst = 10
+
+	retryInitialInterval = 200 * time.Millisecond
+	retryMaxInterval     = 10 * time.Second
+	maxRetries           = 5
+)
+
+var (
+	route53RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kops_dnsprovider_route53_requests_total",
+		Help: "Total number of Route53 API calls made by the dnsprovider, by method.",
+	}, []string{"method"})
+
+	route53RetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kops_dnsprovider_route53_retries_total",
+		Help: "Total number of Route53 API calls retried by the dnsprovider, by method.",
+	}, []string{"method"})
+
+	route53ThrottlesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kops_dnsprovider_route53_throttles_total",
+		Help: "Total number of Route53 API calls that were throttled, by method.",
+	}, []string{"method"})
+)
+
+func init() {
+	prometheus.MustRegister(route53RequestsTotal, route53RetriesTotal, route53ThrottlesTotal)
+}
+
+// rateLimitedService wraps a Route53API so that every call made through it
+// shares a single token-bucket rate limiter, and is retried with jittered
+// exponential backoff on throttling or transient server errors.
+type rateLimitedService struct {
+	inner   Route53API
+	limiter *rate.Limiter
+}
+
+var _ Route53API = &rateLimitedService{}
+
+func newRateLimitedService(inner Route53API, limiter *rate.Limiter) *rateLimitedService {
+	if limiter == nil {
+		limiter = rate.NewLimiter(rate.Limit(defaultRateLimit), defaultRateBurst)
+	}
+	return &rateLimitedService{inner: inner, limiter: limiter}
+}
+
+// withRetry waits for the rate limiter, calls fn, and retries fn with
+// jittered exponential backoff if it fails with a throttling or transient
+// server error, up to maxRetries times.
+func (s *rateLimitedService) withRetry(ctx context.Context, method string, fn func() error) error {
+	route53RequestsTotal.WithLabelValues(method).Inc()
+
+	interval := retryInitialInterval
+	for attempt := 0; ; attempt++ {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if isThrottlingError(err) {
+			route53ThrottlesTotal.WithLabelValues(method).Inc()
+		}
+		if !isRetryableError(err) || attempt >= maxRetries {
+			return err
+		}
+		route53RetriesTotal.WithLabelValues(method).Inc()
+
+		wait := interval/2 + time.Duration(rand.Int63n(int64(interval/2+1)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		interval *= 2
+		if interval > retryMaxInterval {
+			interval = retryMaxInterval
+		}
+	}
+}
+
+func isThrottlingError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "Throttling", "ThrottlingException", "PriorRequestNotComplete":
+			return true
+		}
+	}
+	return false
+}
+
+func isServerError(err error) bool {
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode() >= 500
+	}
+	return false
+}
+
+func isRetryableError(err error) bool {
+	return isThrottlingError(err) || isServerError(err)
+}
+
+func (s *rateLimitedService) CreateHostedZone(ctx context.Context, params *route53.CreateHostedZoneInput, optFns ...func(*route53.Options)) (*route53.CreateHostedZoneOutput, error) {
+	var output *route53.CreateHostedZoneOutput
+	err := s.withRetry(ctx, "CreateHostedZone", func() (err error) {
+		output, err = s.inner.CreateHostedZone(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) DeleteHostedZone(ctx context.Context, params *route53.DeleteHostedZoneInput, optFns ...func(*route53.Options)) (*route53.DeleteHostedZoneOutput, error) {
+	var output *route53.DeleteHostedZoneOutput
+	err := s.withRetry(ctx, "DeleteHostedZone", func() (err error) {
+		output, err = s.inner.DeleteHostedZone(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) ListHostedZones(ctx context.Context, params *route53.ListHostedZonesInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesOutput, error) {
+	var output *route53.ListHostedZonesOutput
+	err := s.withRetry(ctx, "ListHostedZones", func() (err error) {
+		output, err = s.inner.ListHostedZones(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) ListHostedZonesByName(ctx context.Context, params *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error) {
+	var output *route53.ListHostedZonesByNameOutput
+	err := s.withRetry(ctx, "ListHostedZonesByName", func() (err error) {
+		output, err = s.inner.ListHostedZonesByName(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) GetHostedZone(ctx context.Context, params *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (*route53.GetHostedZoneOutput, error) {
+	var output *route53.GetHostedZoneOutput
+	err := s.withRetry(ctx, "GetHostedZone", func() (err error) {
+		output, err = s.inner.GetHostedZone(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error) {
+	var output *route53.ListResourceRecordSetsOutput
+	err := s.withRetry(ctx, "ListResourceRecordSets", func() (err error) {
+		output, err = s.inner.ListResourceRecordSets(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error) {
+	var output *route53.ChangeResourceRecordSetsOutput
+	err := s.withRetry(ctx, "ChangeResourceRecordSets", func() (err error) {
+		output, err = s.inner.ChangeResourceRecordSets(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) GetChange(ctx context.Context, params *route53.GetChangeInput, optFns ...func(*route53.Options)) (*route53.GetChangeOutput, error) {
+	var output *route53.GetChangeOutput
+	err := s.withRetry(ctx, "GetChange", func() (err error) {
+		output, err = s.inner.GetChange(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) ChangeTagsForResource(ctx context.Context, params *route53.ChangeTagsForResourceInput, optFns ...func(*route53.Options)) (*route53.ChangeTagsForResourceOutput, error) {
+	var output *route53.ChangeTagsForResourceOutput
+	err := s.withRetry(ctx, "ChangeTagsForResource", func() (err error) {
+		output, err = s.inner.ChangeTagsForResource(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
+
+func (s *rateLimitedService) ListTagsForResource(ctx context.Context, params *route53.ListTagsForResourceInput, optFns ...func(*route53.Options)) (*route53.ListTagsForResourceOutput, error) {
+	var output *route53.ListTagsForResourceOutput
+	err := s.withRetry(ctx, "ListTagsForResource", func() (err error) {
+		output, err = s.inner.ListTagsForResource(ctx, params, optFns...)
+		return err
+	})
+	return output, err
+}
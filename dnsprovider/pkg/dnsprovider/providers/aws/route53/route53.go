@@ -0,0 +1,678 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package route53 implements the dnsprovider interfaces on top of AWS Route53.
+package route53
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"golang.org/x/time/rate"
+
+	"k8s.io/kops/dnsprovider/pkg/dnsprovider"
+	"k8s.io/kops/dnsprovider/pkg/dnsprovider/rrstype"
+)
+
+const (
+	// ProviderName is the name under which this provider is registered with dnsprovider.
+	ProviderName = "aws-route53"
+
+	hostedZoneIDPrefix = "/hostedzone/"
+)
+
+// Route53API is the subset of the route53.Client surface used by this
+// provider, so that a fake implementation can be substituted in tests.
+type Route53API interface {
+	CreateHostedZone(ctx context.Context, params *route53.CreateHostedZoneInput, optFns ...func(*route53.Options)) (*route53.CreateHostedZoneOutput, error)
+	DeleteHostedZone(ctx context.Context, params *route53.DeleteHostedZoneInput, optFns ...func(*route53.Options)) (*route53.DeleteHostedZoneOutput, error)
+	ListHostedZones(ctx context.Context, params *route53.ListHostedZonesInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesOutput, error)
+	ListHostedZonesByName(ctx context.Context, params *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error)
+	GetHostedZone(ctx context.Context, params *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (*route53.GetHostedZoneOutput, error)
+	ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error)
+	ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error)
+	GetChange(ctx context.Context, params *route53.GetChangeInput, optFns ...func(*route53.Options)) (*route53.GetChangeOutput, error)
+	ChangeTagsForResource(ctx context.Context, params *route53.ChangeTagsForResourceInput, optFns ...func(*route53.Options)) (*route53.ChangeTagsForResourceOutput, error)
+	ListTagsForResource(ctx context.Context, params *route53.ListTagsForResourceInput, optFns ...func(*route53.Options)) (*route53.ListTagsForResourceOutput, error)
+}
+
+// defaultMaxChangesPerBatch and maxValueCharsPerBatch mirror Route53's
+// ChangeResourceRecordSets limits: at most 1000 changes, and at most 32,000
+// characters across every ResourceRecord Value, per call.
+const (
+	defaultMaxChangesPerBatch = 1000
+	maxValueCharsPerBatch     = 32000
+)
+
+// Interface implements dnsprovider.Interface on top of the Route53 API.
+type Interface struct {
+	service Route53API
+
+	// MaxChangesPerBatch caps how many changes ResourceRecordChangeset.Apply
+	// packs into a single ChangeResourceRecordSets call. It defaults to
+	// Route53's own limit of 1000, and is exposed so that batching can be
+	// exercised with smaller numbers in tests.
+	MaxChangesPerBatch int
+}
+
+var _ dnsprovider.Interface = &Interface{}
+
+// Options configures optional behavior of an Interface created via
+// NewWithOptions.
+type Options struct {
+	// Limiter rate-limits every call made through this Interface's
+	// Route53API client. If nil, a token-bucket limiter of
+	// defaultRateLimit requests/sec with a burst of defaultRateBurst is
+	// used. All Zones and ResourceRecordSets created from the same
+	// Interface share this limiter.
+	Limiter *rate.Limiter
+}
+
+// New returns a dnsprovider.Interface backed by the given Route53 API
+// client, rate-limited and retried with the default Options.
+func New(service Route53API) *Interface {
+	return NewWithOptions(service, Options{})
+}
+
+// NewWithOptions returns a dnsprovider.Interface backed by the given
+// Route53 API client, with the given Options applied.
+func NewWithOptions(service Route53API, opts Options) *Interface {
+	return &Interface{
+		service:            newRateLimitedService(service, opts.Limiter),
+		MaxChangesPerBatch: defaultMaxChangesPerBatch,
+	}
+}
+
+// Zones returns the Route53 zones interface.
+func (i *Interface) Zones() (dnsprovider.Zones, bool) {
+	return &Zones{interface_: i}, true
+}
+
+// Zones implements dnsprovider.Zones on top of Route53 hosted zones.
+type Zones struct {
+	interface_ *Interface
+}
+
+var _ dnsprovider.Zones = &Zones{}
+
+// List returns all hosted zones visible to the configured Route53 account.
+func (zones *Zones) List() ([]dnsprovider.Zone, error) {
+	ctx := context.TODO()
+	output, err := zones.interface_.service.ListHostedZones(ctx, &route53.ListHostedZonesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hosted zones: %v", err)
+	}
+	var result []dnsprovider.Zone
+	for _, z := range output.HostedZones {
+		result = append(result, &Zone{impl: z, zones: zones})
+	}
+	return result, nil
+}
+
+// New allocates a Zone object, which can then be passed to Add().
+func (zones *Zones) New(name string) (dnsprovider.Zone, error) {
+	return zones.NewZone(name)
+}
+
+// ZoneOption customizes the Zone object allocated by NewZone, beyond what
+// the generic dnsprovider.Zones.New supports.
+type ZoneOption func(*Zone)
+
+// WithVPCAssociation marks the zone-to-be-created as a private hosted zone
+// associated with the given VPC, so that Zones.Add creates it with the
+// correct VPC block instead of as a public zone.
+func WithVPCAssociation(vpcID, region string) ZoneOption {
+	return func(z *Zone) {
+		z.vpcID = vpcID
+		z.vpcRegion = region
+	}
+}
+
+// NewZone allocates a Zone object, optionally configured via ZoneOption,
+// which can then be passed to Add().
+func (zones *Zones) NewZone(name string, opts ...ZoneOption) (dnsprovider.Zone, error) {
+	z := &Zone{
+		impl: route53types.HostedZone{
+			Name: aws.String(name),
+		},
+		zones: zones,
+	}
+	for _, opt := range opts {
+		opt(z)
+	}
+	return z, nil
+}
+
+// Add creates a new hosted zone for the given Zone's name. If the Zone was
+// allocated with WithVPCAssociation, the hosted zone is created as a
+// private zone associated with that VPC.
+func (zones *Zones) Add(zone dnsprovider.Zone) (dnsprovider.Zone, error) {
+	ctx := context.TODO()
+	z, ok := zone.(*Zone)
+	if !ok {
+		return nil, fmt.Errorf("unexpected zone type %T", zone)
+	}
+	input := &route53.CreateHostedZoneInput{
+		CallerReference: aws.String(fmt.Sprintf("kops-%s", zone.Name())),
+		Name:            aws.String(zone.Name()),
+	}
+	if z.vpcID != "" {
+		input.VPC = &route53types.VPC{
+			VPCId:     aws.String(z.vpcID),
+			VPCRegion: route53types.VPCRegion(z.vpcRegion),
+		}
+		input.HostedZoneConfig = &route53types.HostedZoneConfig{PrivateZone: true}
+	}
+	output, err := zones.interface_.service.CreateHostedZone(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hosted zone %q: %v", zone.Name(), err)
+	}
+	return &Zone{impl: *output.HostedZone, zones: zones}, nil
+}
+
+// FindByNameAndVPC finds the hosted zone named name. If vpcID is empty, any
+// hosted zone (public or private) with that name is a candidate; if more
+// than one matches, the lookup is ambiguous and returns an error. If vpcID
+// is set, only a private zone whose VPC associations (fetched via
+// GetHostedZone) include vpcID/region is returned, which disambiguates
+// between a public and a private zone that share the same name.
+func (zones *Zones) FindByNameAndVPC(name, vpcID, region string) (dnsprovider.Zone, error) {
+	ctx := context.TODO()
+	output, err := zones.interface_.service.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName: aws.String(name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hosted zones named %q: %v", name, err)
+	}
+
+	var matches []route53types.HostedZone
+	for _, hz := range output.HostedZones {
+		if trimTrailingDot(aws.ToString(hz.Name)) != trimTrailingDot(name) {
+			continue
+		}
+		if vpcID == "" {
+			matches = append(matches, hz)
+			continue
+		}
+		if hz.Config == nil || !hz.Config.PrivateZone {
+			continue
+		}
+		getOutput, err := zones.interface_.service.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: hz.Id})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hosted zone %q: %v", aws.ToString(hz.Id), err)
+		}
+		for _, vpc := range getOutput.VPCs {
+			if aws.ToString(vpc.VPCId) == vpcID && string(vpc.VPCRegion) == region {
+				matches = append(matches, hz)
+				break
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no hosted zone named %q found for VPC %q", name, vpcID)
+	case 1:
+		return &Zone{impl: matches[0], zones: zones}, nil
+	default:
+		return nil, fmt.Errorf("found %d hosted zones named %q; specify a VPC to disambiguate", len(matches), name)
+	}
+}
+
+// Remove deletes the given hosted zone.
+func (zones *Zones) Remove(zone dnsprovider.Zone) error {
+	ctx := context.TODO()
+	z, ok := zone.(*Zone)
+	if !ok {
+		return fmt.Errorf("unexpected zone type %T", zone)
+	}
+	_, err := zones.interface_.service.DeleteHostedZone(ctx, &route53.DeleteHostedZoneInput{
+		Id: z.impl.Id,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete hosted zone %q: %v", zone.Name(), err)
+	}
+	return nil
+}
+
+// Zone implements dnsprovider.Zone on top of a Route53 hosted zone.
+type Zone struct {
+	impl  route53types.HostedZone
+	zones *Zones
+
+	// vpcID and vpcRegion are set by WithVPCAssociation on a Zone that has
+	// not yet been created, and are consumed by Zones.Add.
+	vpcID     string
+	vpcRegion string
+}
+
+var _ dnsprovider.Zone = &Zone{}
+
+// Name returns the fully qualified domain name of the zone.
+func (z *Zone) Name() string {
+	return aws.ToString(z.impl.Name)
+}
+
+// ID returns the hosted zone ID, with the "/hostedzone/" prefix removed.
+func (z *Zone) ID() string {
+	return strings.TrimPrefix(aws.ToString(z.impl.Id), hostedZoneIDPrefix)
+}
+
+// ResourceRecordSets returns the ResourceRecordSets interface for this zone.
+func (z *Zone) ResourceRecordSets() (dnsprovider.ResourceRecordSets, bool) {
+	return &ResourceRecordSets{zone: z}, true
+}
+
+var _ dnsprovider.Taggable = &Zone{}
+
+// Tags returns the tags currently attached to the hosted zone.
+func (z *Zone) Tags(ctx context.Context) (map[string]string, error) {
+	output, err := z.zones.interface_.service.ListTagsForResource(ctx, &route53.ListTagsForResourceInput{
+		ResourceType: route53types.TagResourceTypeHostedzone,
+		ResourceId:   aws.String(z.ID()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags for zone %q: %v", z.Name(), err)
+	}
+	tags := make(map[string]string)
+	if output.ResourceTagSet != nil {
+		for _, tag := range output.ResourceTagSet.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// SetTags replaces the hosted zone's tags with the given set.
+func (z *Zone) SetTags(ctx context.Context, tags map[string]string) error {
+	existing, err := z.Tags(ctx)
+	if err != nil {
+		return err
+	}
+
+	var addTags []route53types.Tag
+	for k, v := range tags {
+		addTags = append(addTags, route53types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	var removeKeys []string
+	for k := range existing {
+		if _, keep := tags[k]; !keep {
+			removeKeys = append(removeKeys, k)
+		}
+	}
+
+	_, err = z.zones.interface_.service.ChangeTagsForResource(ctx, &route53.ChangeTagsForResourceInput{
+		ResourceType:  route53types.TagResourceTypeHostedzone,
+		ResourceId:    aws.String(z.ID()),
+		AddTags:       addTags,
+		RemoveTagKeys: removeKeys,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set tags for zone %q: %v", z.Name(), err)
+	}
+	return nil
+}
+
+// ResourceRecordSets implements dnsprovider.ResourceRecordSets on top of Route53.
+type ResourceRecordSets struct {
+	zone *Zone
+}
+
+var _ dnsprovider.ResourceRecordSets = &ResourceRecordSets{}
+
+// List returns every resource record set in the zone.
+//
+// Record types that this provider does not understand are silently skipped,
+// rather than failing the whole listing.
+func (rrsets *ResourceRecordSets) List() ([]dnsprovider.ResourceRecordSet, error) {
+	ctx := context.TODO()
+	output, err := rrsets.zone.zones.interface_.service.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId: rrsets.zone.impl.Id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource record sets for zone %q: %v", rrsets.zone.Name(), err)
+	}
+	var result []dnsprovider.ResourceRecordSet
+	for _, rrs := range output.ResourceRecordSets {
+		rrsType, ok := fromRoute53Type(rrs.Type)
+		if !ok {
+			continue
+		}
+		var rrdatas []string
+		for _, r := range rrs.ResourceRecords {
+			rrdatas = append(rrdatas, aws.ToString(r.Value))
+		}
+		result = append(result, &ResourceRecordSet{
+			name:    aws.ToString(rrs.Name),
+			rrdatas: rrdatas,
+			ttl:     aws.ToInt64(rrs.TTL),
+			rrsType: rrsType,
+		})
+	}
+	return result, nil
+}
+
+// Get returns the resource record sets matching name.
+func (rrsets *ResourceRecordSets) Get(name string) ([]dnsprovider.ResourceRecordSet, error) {
+	all, err := rrsets.List()
+	if err != nil {
+		return nil, err
+	}
+	var result []dnsprovider.ResourceRecordSet
+	for _, rrs := range all {
+		if rrs.Name() == name {
+			result = append(result, rrs)
+		}
+	}
+	return result, nil
+}
+
+// New allocates a ResourceRecordSet, which can then be queued onto a changeset.
+func (rrsets *ResourceRecordSets) New(name string, rrdatas []string, ttl int64, rrsType rrstype.RrsType) dnsprovider.ResourceRecordSet {
+	return &ResourceRecordSet{
+		name:    name,
+		rrdatas: rrdatas,
+		ttl:     ttl,
+		rrsType: rrsType,
+	}
+}
+
+// StartChangeset begins a new batch of changes against this zone's records.
+func (rrsets *ResourceRecordSets) StartChangeset() dnsprovider.ResourceRecordChangeset {
+	return &ResourceRecordChangeset{rrsets: rrsets}
+}
+
+// Zone returns the parent zone.
+func (rrsets *ResourceRecordSets) Zone() dnsprovider.Zone {
+	return rrsets.zone
+}
+
+// ResourceRecordSet implements dnsprovider.ResourceRecordSet.
+type ResourceRecordSet struct {
+	name    string
+	rrdatas []string
+	ttl     int64
+	rrsType rrstype.RrsType
+}
+
+var _ dnsprovider.ResourceRecordSet = &ResourceRecordSet{}
+
+func (r *ResourceRecordSet) Name() string         { return r.name }
+func (r *ResourceRecordSet) Rrdatas() []string     { return r.rrdatas }
+func (r *ResourceRecordSet) Ttl() int64            { return r.ttl }
+func (r *ResourceRecordSet) Type() rrstype.RrsType { return r.rrsType }
+
+// ResourceRecordChangeset implements dnsprovider.ResourceRecordChangeset on
+// top of one or more Route53 ChangeResourceRecordSets calls. Each call is
+// applied atomically by Route53, but a changeset split into several batches
+// is not: if a later batch fails, the earlier batches stay applied.
+type ResourceRecordChangeset struct {
+	rrsets    *ResourceRecordSets
+	additions []dnsprovider.ResourceRecordSet
+	removals  []dnsprovider.ResourceRecordSet
+}
+
+var _ dnsprovider.ResourceRecordChangeset = &ResourceRecordChangeset{}
+var _ dnsprovider.WaitingChangeset = &ResourceRecordChangeset{}
+
+const (
+	waitForSyncInitialInterval = 2 * time.Second
+	waitForSyncMaxInterval     = 30 * time.Second
+)
+
+func (c *ResourceRecordChangeset) Add(rrset dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.additions = append(c.additions, rrset)
+	return c
+}
+
+func (c *ResourceRecordChangeset) Remove(rrset dnsprovider.ResourceRecordSet) dnsprovider.ResourceRecordChangeset {
+	c.removals = append(c.removals, rrset)
+	return c
+}
+
+func (c *ResourceRecordChangeset) IsEmpty() bool {
+	return len(c.additions) == 0 && len(c.removals) == 0
+}
+
+func (c *ResourceRecordChangeset) ResourceRecordSets() dnsprovider.ResourceRecordSets {
+	return c.rrsets
+}
+
+// Apply packs every queued addition and removal into one or more
+// ChangeResourceRecordSets calls, staying under Route53's per-call change
+// count and value size limits, and issues them in order. It returns as soon
+// as every batch is accepted; use ApplyAndWait to block until they have
+// propagated.
+func (c *ResourceRecordChangeset) Apply(ctx context.Context) error {
+	_, err := c.apply(ctx)
+	return err
+}
+
+// ApplyAndWait applies the changeset like Apply, but then polls GetChange
+// for each batch in turn, with exponential backoff, until every batch's
+// status is INSYNC or ctx is done.
+func (c *ResourceRecordChangeset) ApplyAndWait(ctx context.Context) error {
+	changeIDs, err := c.apply(ctx)
+	if err != nil {
+		return err
+	}
+	for _, changeID := range changeIDs {
+		if err := c.waitForSync(ctx, changeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply issues one ChangeResourceRecordSets call per batch and returns the
+// ids of the changes that were successfully applied, in order. If a batch
+// fails, the ids of every batch that was already applied are returned
+// alongside the error, so that callers can tell how much of the changeset
+// took effect.
+func (c *ResourceRecordChangeset) apply(ctx context.Context) ([]string, error) {
+	if c.IsEmpty() {
+		return nil, nil
+	}
+	batches, err := c.buildBatches()
+	if err != nil {
+		return nil, err
+	}
+
+	var appliedChangeIDs []string
+	for i, batch := range batches {
+		input := &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: c.rrsets.zone.impl.Id,
+			ChangeBatch: &route53types.ChangeBatch{
+				Changes: batch,
+			},
+		}
+		output, err := c.rrsets.zone.zones.interface_.service.ChangeResourceRecordSets(ctx, input)
+		if err != nil {
+			return appliedChangeIDs, fmt.Errorf("failed to apply batch %d/%d of resource record changes to zone %q (already-applied changes: %v): %v",
+				i+1, len(batches), c.rrsets.zone.Name(), appliedChangeIDs, err)
+		}
+		if output.ChangeInfo != nil {
+			appliedChangeIDs = append(appliedChangeIDs, aws.ToString(output.ChangeInfo.Id))
+		}
+	}
+	return appliedChangeIDs, nil
+}
+
+// buildBatches converts every queued addition and removal into a
+// route53types.Change, then splits them into batches that each stay under
+// Route53's limits of MaxChangesPerBatch changes and maxValueCharsPerBatch
+// characters across every ResourceRecord Value.
+func (c *ResourceRecordChangeset) buildBatches() ([][]route53types.Change, error) {
+	maxChanges := c.rrsets.zone.zones.interface_.MaxChangesPerBatch
+	if maxChanges <= 0 {
+		maxChanges = defaultMaxChangesPerBatch
+	}
+
+	// Removals are ordered ahead of additions so that a replace queued as
+	// Remove(old).Add(new) for the same name and type deletes the old
+	// record set before the new one is created, rather than issuing a
+	// CREATE against a record set that Route53 still considers existing.
+	var allChanges []route53types.Change
+	for _, rrset := range c.removals {
+		change, err := toChange(route53types.ChangeActionDelete, rrset)
+		if err != nil {
+			return nil, err
+		}
+		allChanges = append(allChanges, change)
+	}
+	for _, rrset := range c.additions {
+		change, err := toChange(route53types.ChangeActionCreate, rrset)
+		if err != nil {
+			return nil, err
+		}
+		allChanges = append(allChanges, change)
+	}
+
+	var batches [][]route53types.Change
+	var current []route53types.Change
+	currentChars := 0
+	for _, change := range allChanges {
+		chars := changeValueChars(change)
+		if len(current) > 0 && (len(current) >= maxChanges || currentChars+chars > maxValueCharsPerBatch) {
+			batches = append(batches, current)
+			current = nil
+			currentChars = 0
+		}
+		current = append(current, change)
+		currentChars += chars
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches, nil
+}
+
+// changeValueChars returns the combined length of every ResourceRecord
+// Value in change, which is what counts against Route53's 32,000 character
+// per-batch limit.
+func changeValueChars(change route53types.Change) int {
+	total := 0
+	if change.ResourceRecordSet != nil {
+		for _, r := range change.ResourceRecordSet.ResourceRecords {
+			total += len(aws.ToString(r.Value))
+		}
+	}
+	return total
+}
+
+// waitForSync polls GetChange for changeID, backing off exponentially
+// starting at waitForSyncInitialInterval and capping at
+// waitForSyncMaxInterval, until the change is INSYNC or ctx is done.
+func (c *ResourceRecordChangeset) waitForSync(ctx context.Context, changeID string) error {
+	service := c.rrsets.zone.zones.interface_.service
+	interval := waitForSyncInitialInterval
+	for {
+		output, err := service.GetChange(ctx, &route53.GetChangeInput{Id: aws.String(changeID)})
+		if err != nil {
+			return fmt.Errorf("failed to get status of change %q: %v", changeID, err)
+		}
+		if output.ChangeInfo != nil && output.ChangeInfo.Status == route53types.ChangeStatusInsync {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > waitForSyncMaxInterval {
+			interval = waitForSyncMaxInterval
+		}
+	}
+}
+
+func toChange(action route53types.ChangeAction, rrset dnsprovider.ResourceRecordSet) (route53types.Change, error) {
+	rrsType, err := toRoute53Type(rrset.Type())
+	if err != nil {
+		return route53types.Change{}, err
+	}
+	var records []route53types.ResourceRecord
+	for _, v := range rrset.Rrdatas() {
+		if rrset.Type() == rrstype.AAAA {
+			if err := validateAAAAValue(v); err != nil {
+				return route53types.Change{}, fmt.Errorf("invalid AAAA record value %q for %q: %v", v, rrset.Name(), err)
+			}
+		}
+		records = append(records, route53types.ResourceRecord{Value: aws.String(v)})
+	}
+	return route53types.Change{
+		Action: action,
+		ResourceRecordSet: &route53types.ResourceRecordSet{
+			Name:            aws.String(rrset.Name()),
+			Type:            rrsType,
+			TTL:             aws.Int64(rrset.Ttl()),
+			ResourceRecords: records,
+		},
+	}, nil
+}
+
+// validateAAAAValue checks that v is a well-formed IPv6 address, e.g. "2001:db8::1".
+func validateAAAAValue(v string) error {
+	ip := net.ParseIP(v)
+	if ip == nil {
+		return fmt.Errorf("not a valid IP address")
+	}
+	if ip.To4() != nil {
+		return fmt.Errorf("not a valid IPv6 address")
+	}
+	return nil
+}
+
+func toRoute53Type(t rrstype.RrsType) (route53types.RRType, error) {
+	switch t {
+	case rrstype.A:
+		return route53types.RRTypeA, nil
+	case rrstype.AAAA:
+		return route53types.RRTypeAaaa, nil
+	case rrstype.CNAME:
+		return route53types.RRTypeCname, nil
+	default:
+		return "", fmt.Errorf("unsupported resource record type: %v", t)
+	}
+}
+
+func fromRoute53Type(t route53types.RRType) (rrstype.RrsType, bool) {
+	switch t {
+	case route53types.RRTypeA:
+		return rrstype.A, true
+	case route53types.RRTypeAaaa:
+		return rrstype.AAAA, true
+	case route53types.RRTypeCname:
+		return rrstype.CNAME, true
+	default:
+		return "", false
+	}
+}
+
+// trimTrailingDot strips a trailing "." from a domain name, so that a
+// caller-supplied name (e.g. "example.com") compares equal to the FQDN form
+// Route53 returns from its APIs (e.g. "example.com.").
+func trimTrailingDot(name string) string {
+	return strings.TrimSuffix(name, ".")
+}
@@ -21,7 +21,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"k8s.io/kops/dnsprovider/pkg/dnsprovider"
 	route53testing "k8s.io/kops/dnsprovider/pkg/dnsprovider/providers/aws/route53/stubs"
@@ -29,6 +33,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"golang.org/x/time/rate"
 	"k8s.io/kops/dnsprovider/pkg/dnsprovider/tests"
 )
 
@@ -40,7 +45,9 @@ func newTestInterface() (dnsprovider.Interface, error) {
 
 func newFakeInterface() (dnsprovider.Interface, error) {
 	service := route53testing.NewRoute53APIStub()
-	iface := New(service)
+	// Tests make far more calls per second than the default 5rps limiter
+	// allows, so give the shared test interface an unlimited one.
+	iface := NewWithOptions(service, Options{Limiter: rate.NewLimiter(rate.Inf, 0)})
 	// Add a fake zone to test against.
 	params := &route53.CreateHostedZoneInput{
 		CallerReference: aws.String("Nonce"),       // Required
@@ -125,6 +132,11 @@ func getExampleRrs(zone dnsprovider.Zone) dnsprovider.ResourceRecordSet {
 	return rrsets.New("www11."+zone.Name(), []string{"10.10.10.10", "169.20.20.20"}, 180, rrstype.A)
 }
 
+func getExampleRrsAAAA(zone dnsprovider.Zone) dnsprovider.ResourceRecordSet {
+	rrsets, _ := zone.ResourceRecordSets()
+	return rrsets.New("www11."+zone.Name(), []string{"2001:db8::1", "2001:db8::2"}, 180, rrstype.AAAA)
+}
+
 func addRrsetOrFail(ctx context.Context, t *testing.T, rrsets dnsprovider.ResourceRecordSets, rrset dnsprovider.ResourceRecordSet) {
 	err := rrsets.StartChangeset().Add(rrset).Apply(ctx)
 	if err != nil {
@@ -143,7 +155,7 @@ func TestZonesID(t *testing.T) {
 
 	// Check /hostedzone/ prefix is removed
 	zoneID := zone.ID()
-	if zoneID != zone.Name() {
+	if zoneID == "" || strings.Contains(zoneID, "/hostedzone/") {
 		t.Fatalf("Unexpected zone id: %q", zoneID)
 	}
 }
@@ -170,6 +182,125 @@ func TestZoneAddSuccess(t *testing.T) {
 	t.Logf("Successfully added managed DNS zone: %v", zone)
 }
 
+/* TestZoneTags verifies that tagging a zone and reading the tags back works,
+and that kops-owned zones can be picked out of a list by tag. */
+func TestZoneTags(t *testing.T) {
+	ctx := context.Background()
+	z := zones(t)
+
+	taggedName := "tagged.testing"
+	taggedInput, err := z.New(taggedName)
+	if err != nil {
+		t.Fatalf("Failed to allocate new zone object %s: %v", taggedName, err)
+	}
+	taggedZone, err := z.Add(taggedInput)
+	if err != nil {
+		t.Fatalf("Failed to create new managed DNS zone %s: %v", taggedName, err)
+	}
+	defer z.Remove(taggedZone)
+
+	untaggedName := "untagged.testing"
+	untaggedInput, err := z.New(untaggedName)
+	if err != nil {
+		t.Fatalf("Failed to allocate new zone object %s: %v", untaggedName, err)
+	}
+	untaggedZone, err := z.Add(untaggedInput)
+	if err != nil {
+		t.Fatalf("Failed to create new managed DNS zone %s: %v", untaggedName, err)
+	}
+	defer z.Remove(untaggedZone)
+
+	taggable, ok := taggedZone.(dnsprovider.Taggable)
+	if !ok {
+		t.Fatalf("Zone %v does not implement dnsprovider.Taggable", taggedZone)
+	}
+	wantTags := map[string]string{"KubernetesCluster": "my-cluster.example.com"}
+	if err := taggable.SetTags(ctx, wantTags); err != nil {
+		t.Fatalf("Failed to set tags on zone %s: %v", taggedName, err)
+	}
+
+	gotTags, err := taggable.Tags(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get tags for zone %s: %v", taggedName, err)
+	}
+	if gotTags["KubernetesCluster"] != "my-cluster.example.com" {
+		t.Errorf("Unexpected tags for zone %s: %v", taggedName, gotTags)
+	}
+
+	all, err := z.List()
+	if err != nil {
+		t.Fatalf("Failed to list zones: %v", err)
+	}
+	matches, err := dnsprovider.FilterZonesByTag(ctx, all, "KubernetesCluster", "my-cluster.example.com")
+	if err != nil {
+		t.Fatalf("Failed to filter zones by tag: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Name() != taggedName {
+		t.Errorf("Expected filter to return only %s, got %v", taggedName, matches)
+	}
+}
+
+/* TestFindByNameAndVPC verifies that a private zone sharing a name with a
+public zone can be looked up unambiguously once a VPC is specified. */
+func TestFindByNameAndVPC(t *testing.T) {
+	z := zones(t)
+	r53Zones, ok := z.(*Zones)
+	if !ok {
+		t.Fatalf("Zones %v is not a *route53.Zones", z)
+	}
+
+	name := firstZone(t).Name()
+	const vpcID = "vpc-1234"
+	const region = "us-east-1"
+
+	privateInput, err := r53Zones.NewZone(name, WithVPCAssociation(vpcID, region))
+	if err != nil {
+		t.Fatalf("Failed to allocate new private zone object %s: %v", name, err)
+	}
+	privateZone, err := z.Add(privateInput)
+	if err != nil {
+		t.Fatalf("Failed to create new private managed DNS zone %s: %v", name, err)
+	}
+	defer z.Remove(privateZone)
+
+	if _, err := r53Zones.FindByNameAndVPC(name, "", ""); err == nil {
+		t.Errorf("Expected lookup by name alone to fail with ambiguous zones named %s, but it succeeded", name)
+	}
+
+	found, err := r53Zones.FindByNameAndVPC(name, vpcID, region)
+	if err != nil {
+		t.Fatalf("Failed to find private zone %s for VPC %s: %v", name, vpcID, err)
+	}
+	if found.ID() != privateZone.ID() {
+		t.Errorf("FindByNameAndVPC returned zone %v, expected the private zone %v", found, privateZone)
+	}
+}
+
+/* TestFindByNameAndVPCTrailingDot verifies that FindByNameAndVPC matches a
+hosted zone whose name Route53 returned as an FQDN (with a trailing dot)
+against a caller-supplied name without one. */
+func TestFindByNameAndVPCTrailingDot(t *testing.T) {
+	service := route53testing.NewRoute53APIStub()
+	iface := NewWithOptions(service, Options{Limiter: rate.NewLimiter(rate.Inf, 0)})
+	_, err := iface.service.CreateHostedZone(context.TODO(), &route53.CreateHostedZoneInput{
+		CallerReference: aws.String("Nonce"),
+		Name:            aws.String("dotted.example.com."),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create hosted zone: %v", err)
+	}
+	zonesIface, _ := iface.Zones()
+	r53Zones := zonesIface.(*Zones)
+
+	found, err := r53Zones.FindByNameAndVPC("dotted.example.com", "", "")
+	if err != nil {
+		t.Fatalf("Failed to find hosted zone by undotted name against a dotted FQDN: %v", err)
+	}
+	if found.Name() != "dotted.example.com." {
+		t.Errorf("FindByNameAndVPC returned unexpected zone %v", found)
+	}
+}
+
 /* TestResourceRecordSetsList verifies that listing of RRS's succeeds */
 func TestResourceRecordSetsList(t *testing.T) {
 	listRrsOrFail(t, rrs(t, firstZone(t)))
@@ -277,6 +408,48 @@ func TestResourceRecordSetsRemoveGone(t *testing.T) {
 	}
 }
 
+/* TestResourceRecordSetsAAAA verifies that AAAA records round-trip alongside A records of the same name */
+func TestResourceRecordSetsAAAA(t *testing.T) {
+	ctx := context.Background()
+
+	zone := firstZone(t)
+	sets := rrs(t, zone)
+
+	aRrset := getExampleRrs(zone)
+	addRrsetOrFail(ctx, t, sets, aRrset)
+	defer sets.StartChangeset().Remove(aRrset).Apply(ctx)
+
+	aaaaRrset := getExampleRrsAAAA(zone)
+	addRrsetOrFail(ctx, t, sets, aaaaRrset)
+	defer sets.StartChangeset().Remove(aaaaRrset).Apply(ctx)
+
+	list := listRrsOrFail(t, sets)
+	var foundA, foundAAAA dnsprovider.ResourceRecordSet
+	for _, record := range list {
+		if record.Name() != aaaaRrset.Name() {
+			continue
+		}
+		switch record.Type() {
+		case rrstype.A:
+			foundA = record
+		case rrstype.AAAA:
+			foundAAAA = record
+		}
+	}
+	if foundA == nil {
+		t.Errorf("Failed to find A record %s alongside AAAA record of the same name", aRrset.Name())
+	}
+	if foundAAAA == nil {
+		t.Fatalf("Failed to find added AAAA resource record set %s", aaaaRrset.Name())
+	}
+	gotValues := append([]string(nil), foundAAAA.Rrdatas()...)
+	sort.Strings(gotValues)
+	wantValues := []string{"2001:db8::1", "2001:db8::2"}
+	if !reflect.DeepEqual(gotValues, wantValues) {
+		t.Errorf("Unexpected AAAA record values: got %v, want %v", gotValues, wantValues)
+	}
+}
+
 /* TestResourceRecordSetsReplace verifies that replacing an RRS works */
 func TestResourceRecordSetsReplace(t *testing.T) {
 	zone := firstZone(t)
@@ -302,3 +475,127 @@ func TestContract(t *testing.T) {
 
 	tests.TestContract(t, sets)
 }
+
+/* TestApplyAndWait verifies that ApplyAndWait blocks until the stub reports
+the change as INSYNC, and surfaces context.DeadlineExceeded cleanly if the
+context expires first. */
+func TestApplyAndWait(t *testing.T) {
+	service := route53testing.NewRoute53APIStub()
+	iface := New(service)
+	_, err := iface.service.CreateHostedZone(context.TODO(), &route53.CreateHostedZoneInput{
+		CallerReference: aws.String("Nonce"),
+		Name:            aws.String("waiting.example.com"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create hosted zone: %v", err)
+	}
+	zonesIface, _ := iface.Zones()
+	zoneList, err := zonesIface.List()
+	if err != nil || len(zoneList) != 1 {
+		t.Fatalf("Failed to list zones: %v (list: %v)", err, zoneList)
+	}
+	zone := zoneList[0]
+	sets, _ := zone.ResourceRecordSets()
+
+	t.Run("blocks until insync", func(t *testing.T) {
+		service.SetInsyncAfterCalls(1)
+		rrset := sets.New("www1."+zone.Name(), []string{"1.2.3.4"}, 180, rrstype.A)
+		changeset := sets.StartChangeset().Add(rrset)
+		waiting, ok := changeset.(dnsprovider.WaitingChangeset)
+		if !ok {
+			t.Fatalf("Changeset %v does not implement dnsprovider.WaitingChangeset", changeset)
+		}
+		start := time.Now()
+		if err := waiting.ApplyAndWait(context.Background()); err != nil {
+			t.Fatalf("ApplyAndWait failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 2*time.Second {
+			t.Errorf("Expected ApplyAndWait to block for at least one backoff interval, took %v", elapsed)
+		}
+		sets.StartChangeset().Remove(rrset).Apply(context.Background())
+	})
+
+	t.Run("surfaces context deadline exceeded", func(t *testing.T) {
+		service.SetInsyncAfterCalls(1000)
+		rrset := sets.New("www2."+zone.Name(), []string{"5.6.7.8"}, 180, rrstype.A)
+		changeset := sets.StartChangeset().Add(rrset)
+		waiting := changeset.(dnsprovider.WaitingChangeset)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := waiting.ApplyAndWait(ctx); err != context.DeadlineExceeded {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+		sets.StartChangeset().Remove(rrset).Apply(context.Background())
+	})
+}
+
+/* TestApplyBatching verifies that a changeset larger than Route53's
+1000-change-per-call limit is automatically split into multiple
+ChangeResourceRecordSets calls. */
+func TestApplyBatching(t *testing.T) {
+	ctx := context.Background()
+
+	service := route53testing.NewRoute53APIStub()
+	iface := New(service)
+	_, err := iface.service.CreateHostedZone(context.TODO(), &route53.CreateHostedZoneInput{
+		CallerReference: aws.String("Nonce"),
+		Name:            aws.String("batching.example.com"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create hosted zone: %v", err)
+	}
+	zonesIface, _ := iface.Zones()
+	zoneList, err := zonesIface.List()
+	if err != nil || len(zoneList) != 1 {
+		t.Fatalf("Failed to list zones: %v (list: %v)", err, zoneList)
+	}
+	zone := zoneList[0]
+	sets, _ := zone.ResourceRecordSets()
+
+	const numRecords = 2500
+	changeset := sets.StartChangeset()
+	for i := 0; i < numRecords; i++ {
+		name := fmt.Sprintf("host-%d.%s", i, zone.Name())
+		changeset.Add(sets.New(name, []string{"1.2.3.4"}, 180, rrstype.A))
+	}
+
+	if err := changeset.Apply(ctx); err != nil {
+		t.Fatalf("Failed to apply large changeset: %v", err)
+	}
+	if service.ChangeResourceRecordSetsCalls != 3 {
+		t.Errorf("Expected %d additions to be split into exactly 3 ChangeResourceRecordSets calls, got %d", numRecords, service.ChangeResourceRecordSetsCalls)
+	}
+}
+
+/* TestRetryOnThrottle verifies that a ChangeResourceRecordSets call that is
+throttled by the backend is retried transparently, and that the changeset
+still succeeds. */
+func TestRetryOnThrottle(t *testing.T) {
+	ctx := context.Background()
+	service := route53testing.NewRoute53APIStub()
+	iface := NewWithOptions(service, Options{Limiter: rate.NewLimiter(rate.Inf, 0)})
+	_, err := iface.service.CreateHostedZone(ctx, &route53.CreateHostedZoneInput{
+		CallerReference: aws.String("Nonce"),
+		Name:            aws.String("throttled.example.com"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create hosted zone: %v", err)
+	}
+	zonesIface, _ := iface.Zones()
+	zoneList, err := zonesIface.List()
+	if err != nil || len(zoneList) != 1 {
+		t.Fatalf("Failed to list zones: %v (list: %v)", err, zoneList)
+	}
+	zone := zoneList[0]
+	sets, _ := zone.ResourceRecordSets()
+
+	service.SetThrottleResponses(2)
+	rrset := sets.New("www."+zone.Name(), []string{"1.2.3.4"}, 180, rrstype.A)
+	changeset := sets.StartChangeset().Add(rrset)
+	if err := changeset.Apply(ctx); err != nil {
+		t.Fatalf("Expected Apply to succeed after retrying throttled calls, got: %v", err)
+	}
+	if service.ChangeResourceRecordSetsCalls != 3 {
+		t.Errorf("Expected 2 throttled calls plus 1 successful call, got %d calls", service.ChangeResourceRecordSetsCalls)
+	}
+}
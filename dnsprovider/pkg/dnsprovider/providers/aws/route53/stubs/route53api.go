@@ -0,0 +1,314 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package route53testing provides a fake, in-memory implementation of the
+// Route53 API surface that the route53 dnsprovider depends on, so that it
+// can be unit tested without talking to AWS.
+package route53testing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	smithy "github.com/aws/smithy-go"
+)
+
+// Route53APIStub is a minimal stand-in for route53.Client, backed by
+// in-memory maps rather than real AWS calls.
+type Route53APIStub struct {
+	zones      map[string]route53types.HostedZone
+	recordSets map[string]map[string]route53types.ResourceRecordSet // hosted zone id -> "name type" -> record set
+	tags       map[string]map[string]string                         // hosted zone id -> tag key -> tag value
+	vpcs       map[string][]route53types.VPC                        // hosted zone id -> associated VPCs (private zones only)
+	changes    map[string]*changeState                              // change id -> propagation state
+	nextID           int
+	nextChangeID     int
+	insyncAfterCalls int
+
+	// ChangeResourceRecordSetsCalls counts how many times
+	// ChangeResourceRecordSets has been invoked, so that tests can assert
+	// on how a large changeset was batched or retried.
+	ChangeResourceRecordSetsCalls int
+
+	// throttleResponsesRemaining, when positive, makes the next that many
+	// calls to ChangeResourceRecordSets fail with a synthetic Throttling
+	// error, so that retry behavior can be exercised without a real
+	// Route53 account.
+	throttleResponsesRemaining int
+}
+
+// SetThrottleResponses configures this stub to return a synthetic
+// Throttling error for the next n calls to ChangeResourceRecordSets,
+// before it starts succeeding normally.
+func (r *Route53APIStub) SetThrottleResponses(n int) {
+	r.throttleResponsesRemaining = n
+}
+
+// Route53's own limits on a single ChangeResourceRecordSets call.
+const (
+	maxChangesPerBatch    = 1000
+	maxValueCharsPerBatch = 32000
+)
+
+// NewRoute53APIStub returns an empty Route53APIStub.
+func NewRoute53APIStub() *Route53APIStub {
+	return &Route53APIStub{
+		zones:      make(map[string]route53types.HostedZone),
+		recordSets: make(map[string]map[string]route53types.ResourceRecordSet),
+		tags:       make(map[string]map[string]string),
+		vpcs:       make(map[string][]route53types.VPC),
+		changes:    make(map[string]*changeState),
+	}
+}
+
+func (r *Route53APIStub) CreateHostedZone(ctx context.Context, input *route53.CreateHostedZoneInput, optFns ...func(*route53.Options)) (*route53.CreateHostedZoneOutput, error) {
+	// This stub stores the hosted zone name exactly as given, trailing dot
+	// or not; lookups (e.g. ListHostedZonesByName) normalize the trailing
+	// dot on both sides, matching how real Route53 treats names.
+	name := aws.ToString(input.Name)
+	r.nextID++
+	id := fmt.Sprintf("/hostedzone/FAKE%d", r.nextID)
+
+	config := input.HostedZoneConfig
+	if config == nil {
+		config = &route53types.HostedZoneConfig{PrivateZone: false}
+	}
+	hz := route53types.HostedZone{
+		Id:     aws.String(id),
+		Name:   aws.String(name),
+		Config: config,
+	}
+	r.zones[id] = hz
+	r.recordSets[id] = make(map[string]route53types.ResourceRecordSet)
+	r.tags[id] = make(map[string]string)
+	if input.VPC != nil {
+		r.vpcs[id] = []route53types.VPC{*input.VPC}
+	}
+	return &route53.CreateHostedZoneOutput{HostedZone: &hz}, nil
+}
+
+func (r *Route53APIStub) DeleteHostedZone(ctx context.Context, input *route53.DeleteHostedZoneInput, optFns ...func(*route53.Options)) (*route53.DeleteHostedZoneOutput, error) {
+	id := aws.ToString(input.Id)
+	if _, exists := r.zones[id]; !exists {
+		return nil, fmt.Errorf("NoSuchHostedZone: hosted zone %q not found", id)
+	}
+	delete(r.zones, id)
+	delete(r.recordSets, id)
+	delete(r.tags, id)
+	delete(r.vpcs, id)
+	return &route53.DeleteHostedZoneOutput{}, nil
+}
+
+func (r *Route53APIStub) ListHostedZones(ctx context.Context, input *route53.ListHostedZonesInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesOutput, error) {
+	var zones []route53types.HostedZone
+	for _, z := range r.zones {
+		zones = append(zones, z)
+	}
+	return &route53.ListHostedZonesOutput{HostedZones: zones}, nil
+}
+
+func (r *Route53APIStub) ListHostedZonesByName(ctx context.Context, input *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error) {
+	dnsName := aws.ToString(input.DNSName)
+	var zones []route53types.HostedZone
+	for _, z := range r.zones {
+		if dnsName != "" && strings.TrimSuffix(aws.ToString(z.Name), ".") != strings.TrimSuffix(dnsName, ".") {
+			continue
+		}
+		zones = append(zones, z)
+	}
+	return &route53.ListHostedZonesByNameOutput{HostedZones: zones}, nil
+}
+
+func (r *Route53APIStub) GetHostedZone(ctx context.Context, input *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (*route53.GetHostedZoneOutput, error) {
+	id := aws.ToString(input.Id)
+	hz, ok := r.zones[id]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchHostedZone: hosted zone %q not found", id)
+	}
+	return &route53.GetHostedZoneOutput{
+		HostedZone: &hz,
+		VPCs:       r.vpcs[id],
+	}, nil
+}
+
+func (r *Route53APIStub) ListResourceRecordSets(ctx context.Context, input *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error) {
+	id := aws.ToString(input.HostedZoneId)
+	sets, ok := r.recordSets[id]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchHostedZone: hosted zone %q not found", id)
+	}
+	var result []route53types.ResourceRecordSet
+	for _, rrs := range sets {
+		result = append(result, rrs)
+	}
+	return &route53.ListResourceRecordSetsOutput{ResourceRecordSets: result}, nil
+}
+
+func (r *Route53APIStub) ChangeResourceRecordSets(ctx context.Context, input *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error) {
+	r.ChangeResourceRecordSetsCalls++
+
+	if r.throttleResponsesRemaining > 0 {
+		r.throttleResponsesRemaining--
+		return nil, &smithy.GenericAPIError{Code: "Throttling", Message: "synthetic throttle injected by Route53APIStub"}
+	}
+
+	id := aws.ToString(input.HostedZoneId)
+	sets, ok := r.recordSets[id]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchHostedZone: hosted zone %q not found", id)
+	}
+
+	changes := input.ChangeBatch.Changes
+	if len(changes) > maxChangesPerBatch {
+		return nil, fmt.Errorf("InvalidChangeBatch: batch contains %d changes, which exceeds the limit of %d", len(changes), maxChangesPerBatch)
+	}
+	valueChars := 0
+	for _, change := range changes {
+		if change.ResourceRecordSet == nil {
+			continue
+		}
+		for _, rec := range change.ResourceRecordSet.ResourceRecords {
+			valueChars += len(aws.ToString(rec.Value))
+		}
+	}
+	if valueChars > maxValueCharsPerBatch {
+		return nil, fmt.Errorf("InvalidChangeBatch: batch contains %d characters of record values, which exceeds the limit of %d", valueChars, maxValueCharsPerBatch)
+	}
+
+	for _, change := range changes {
+		rrs := change.ResourceRecordSet
+		key := recordKey(aws.ToString(rrs.Name), rrs.Type)
+		switch change.Action {
+		case route53types.ChangeActionCreate:
+			if _, exists := sets[key]; exists {
+				return nil, fmt.Errorf("InvalidChangeBatch: record set %q of type %q already exists", aws.ToString(rrs.Name), rrs.Type)
+			}
+			sets[key] = *rrs
+		case route53types.ChangeActionDelete:
+			if _, exists := sets[key]; !exists {
+				return nil, fmt.Errorf("InvalidChangeBatch: record set %q of type %q does not exist", aws.ToString(rrs.Name), rrs.Type)
+			}
+			delete(sets, key)
+		case route53types.ChangeActionUpsert:
+			sets[key] = *rrs
+		default:
+			return nil, fmt.Errorf("unsupported change action %q", change.Action)
+		}
+	}
+	r.nextChangeID++
+	changeID := fmt.Sprintf("/change/FAKECHANGE%d", r.nextChangeID)
+	r.changes[changeID] = &changeState{pendingCalls: r.insyncAfterCalls}
+
+	return &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53types.ChangeInfo{
+			Id:     aws.String(changeID),
+			Status: r.changes[changeID].status(),
+		},
+	}, nil
+}
+
+// GetChange reports a change as PENDING until it has been queried
+// insyncAfterCalls times, at which point it flips to INSYNC, simulating
+// Route53's asynchronous propagation.
+func (r *Route53APIStub) GetChange(ctx context.Context, input *route53.GetChangeInput, optFns ...func(*route53.Options)) (*route53.GetChangeOutput, error) {
+	id := aws.ToString(input.Id)
+	state, ok := r.changes[id]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchChange: change %q not found", id)
+	}
+	status := state.status()
+	if state.pendingCalls > 0 {
+		state.pendingCalls--
+	}
+	return &route53.GetChangeOutput{
+		ChangeInfo: &route53types.ChangeInfo{
+			Id:     input.Id,
+			Status: status,
+		},
+	}, nil
+}
+
+// SetInsyncAfterCalls configures how many times GetChange must be called
+// for a change before its status flips from PENDING to INSYNC. The
+// default, 0, makes every change INSYNC immediately.
+func (r *Route53APIStub) SetInsyncAfterCalls(n int) {
+	r.insyncAfterCalls = n
+}
+
+// changeState tracks how many more times a change should report PENDING
+// before flipping to INSYNC.
+type changeState struct {
+	pendingCalls int
+}
+
+func (s *changeState) status() route53types.ChangeStatus {
+	if s.pendingCalls > 0 {
+		return route53types.ChangeStatusPending
+	}
+	return route53types.ChangeStatusInsync
+}
+
+func recordKey(name string, t route53types.RRType) string {
+	return strings.ToLower(name) + " " + string(t)
+}
+
+func (r *Route53APIStub) ChangeTagsForResource(ctx context.Context, input *route53.ChangeTagsForResourceInput, optFns ...func(*route53.Options)) (*route53.ChangeTagsForResourceOutput, error) {
+	id := hostedZoneResourceID(aws.ToString(input.ResourceId))
+	tags, ok := r.tags[id]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchHostedZone: hosted zone %q not found", id)
+	}
+	for _, tag := range input.AddTags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	for _, key := range input.RemoveTagKeys {
+		delete(tags, key)
+	}
+	return &route53.ChangeTagsForResourceOutput{}, nil
+}
+
+func (r *Route53APIStub) ListTagsForResource(ctx context.Context, input *route53.ListTagsForResourceInput, optFns ...func(*route53.Options)) (*route53.ListTagsForResourceOutput, error) {
+	id := hostedZoneResourceID(aws.ToString(input.ResourceId))
+	tags, ok := r.tags[id]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchHostedZone: hosted zone %q not found", id)
+	}
+	var resourceTags []route53types.Tag
+	for k, v := range tags {
+		resourceTags = append(resourceTags, route53types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return &route53.ListTagsForResourceOutput{
+		ResourceTagSet: &route53types.ResourceTagSet{
+			ResourceId:   input.ResourceId,
+			ResourceType: input.ResourceType,
+			Tags:         resourceTags,
+		},
+	}, nil
+}
+
+// hostedZoneResourceID maps the bare hosted zone ID used by
+// ChangeTagsForResource/ListTagsForResource back to the "/hostedzone/..."
+// form used as the key elsewhere in the stub.
+func hostedZoneResourceID(resourceID string) string {
+	if strings.HasPrefix(resourceID, "/hostedzone/") {
+		return resourceID
+	}
+	return "/hostedzone/" + resourceID
+}
@@ -0,0 +1,28 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rrstype defines the record types that dnsprovider.ResourceRecordSets
+// implementations are expected to understand.
+package rrstype
+
+// RrsType is the type of a DNS resource record, e.g. "A" or "CNAME".
+type RrsType string
+
+const (
+	A     RrsType = "A"
+	AAAA  RrsType = "AAAA"
+	CNAME RrsType = "CNAME"
+)
@@ -0,0 +1,172 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tests provides a common test suite that every dnsprovider
+// implementation can run against its own Zone, so that the contract of the
+// dnsprovider interfaces is enforced consistently across providers.
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/kops/dnsprovider/pkg/dnsprovider"
+	"k8s.io/kops/dnsprovider/pkg/dnsprovider/rrstype"
+)
+
+func rrsetsOrFail(t *testing.T, zone dnsprovider.Zone) dnsprovider.ResourceRecordSets {
+	rrsets, supported := zone.ResourceRecordSets()
+	if !supported {
+		t.Fatalf("ResourceRecordSets interface not supported by zone %v", zone)
+	}
+	return rrsets
+}
+
+// CommonTestResourceRecordSetsReplace verifies that removing a resource
+// record set and adding back a replacement with the same name, in the same
+// changeset, results in only the replacement value being visible.
+func CommonTestResourceRecordSetsReplace(t *testing.T, zone dnsprovider.Zone) {
+	ctx := context.Background()
+	rrsets := rrsetsOrFail(t, zone)
+
+	name := "replace-test." + zone.Name()
+	original := rrsets.New(name, []string{"8.8.8.8"}, 180, rrstype.A)
+	if err := rrsets.StartChangeset().Add(original).Apply(ctx); err != nil {
+		t.Fatalf("Failed to add original resource record set: %v", err)
+	}
+
+	replacement := rrsets.New(name, []string{"9.9.9.9"}, 180, rrstype.A)
+	changeset := rrsets.StartChangeset().Remove(original).Add(replacement)
+	if err := changeset.Apply(ctx); err != nil {
+		t.Fatalf("Failed to replace resource record set: %v", err)
+	}
+	defer rrsets.StartChangeset().Remove(replacement).Apply(ctx)
+
+	found, err := rrsets.Get(name)
+	if err != nil {
+		t.Fatalf("Failed to get resource record set %s: %v", name, err)
+	}
+	if len(found) != 1 || found[0].Rrdatas()[0] != "9.9.9.9" {
+		t.Fatalf("Expected replacement record set to be the only value for %s, got %v", name, found)
+	}
+}
+
+// CommonTestResourceRecordSetsReplaceAll verifies that an existing resource
+// record set can be removed and a record with a different name created in
+// its place, in the same changeset.
+func CommonTestResourceRecordSetsReplaceAll(t *testing.T, zone dnsprovider.Zone) {
+	ctx := context.Background()
+	rrsets := rrsetsOrFail(t, zone)
+
+	oldName := "replaceall-old." + zone.Name()
+	newName := "replaceall-new." + zone.Name()
+	original := rrsets.New(oldName, []string{"8.8.8.8"}, 180, rrstype.A)
+	if err := rrsets.StartChangeset().Add(original).Apply(ctx); err != nil {
+		t.Fatalf("Failed to add original resource record set: %v", err)
+	}
+
+	replacement := rrsets.New(newName, []string{"9.9.9.9"}, 180, rrstype.A)
+	changeset := rrsets.StartChangeset().Remove(original).Add(replacement)
+	if err := changeset.Apply(ctx); err != nil {
+		t.Fatalf("Failed to replace resource record set: %v", err)
+	}
+	defer rrsets.StartChangeset().Remove(replacement).Apply(ctx)
+
+	if found, err := rrsets.Get(oldName); err != nil {
+		t.Fatalf("Failed to get resource record set %s: %v", oldName, err)
+	} else if len(found) != 0 {
+		t.Errorf("Expected old record set %s to be gone, got %v", oldName, found)
+	}
+	if found, err := rrsets.Get(newName); err != nil {
+		t.Fatalf("Failed to get resource record set %s: %v", newName, err)
+	} else if len(found) != 1 {
+		t.Errorf("Expected new record set %s to be present, got %v", newName, found)
+	}
+}
+
+// CommonTestResourceRecordSetsDifferentTypes verifies that record sets with
+// the same name but different types can coexist.
+func CommonTestResourceRecordSetsDifferentTypes(t *testing.T, zone dnsprovider.Zone) {
+	ctx := context.Background()
+	rrsets := rrsetsOrFail(t, zone)
+
+	name := "same-name." + zone.Name()
+	a := rrsets.New(name, []string{"8.8.8.8"}, 180, rrstype.A)
+	cname := rrsets.New(name, []string{"other." + zone.Name()}, 180, rrstype.CNAME)
+	aaaa := rrsets.New(name, []string{"2001:db8::1"}, 180, rrstype.AAAA)
+
+	if err := rrsets.StartChangeset().Add(a).Apply(ctx); err != nil {
+		t.Fatalf("Failed to add A record: %v", err)
+	}
+	defer rrsets.StartChangeset().Remove(a).Apply(ctx)
+
+	if err := rrsets.StartChangeset().Add(cname).Apply(ctx); err != nil {
+		t.Fatalf("Failed to add CNAME record with same name: %v", err)
+	}
+	defer rrsets.StartChangeset().Remove(cname).Apply(ctx)
+
+	if err := rrsets.StartChangeset().Add(aaaa).Apply(ctx); err != nil {
+		t.Fatalf("Failed to add AAAA record with same name: %v", err)
+	}
+	defer rrsets.StartChangeset().Remove(aaaa).Apply(ctx)
+
+	found, err := rrsets.Get(name)
+	if err != nil {
+		t.Fatalf("Failed to get resource record sets %s: %v", name, err)
+	}
+	if len(found) != 3 {
+		t.Fatalf("Expected 3 resource record sets named %s with different types, got %d: %v", name, len(found), found)
+	}
+}
+
+// TestContract exercises the general dnsprovider.ResourceRecordSets contract:
+// an empty changeset is a no-op, and queued changes are not visible until
+// Apply is called.
+func TestContract(t *testing.T, rrsets dnsprovider.ResourceRecordSets) {
+	ctx := context.Background()
+
+	empty := rrsets.StartChangeset()
+	if !empty.IsEmpty() {
+		t.Errorf("Expected a freshly created changeset to be empty")
+	}
+	if err := empty.Apply(ctx); err != nil {
+		t.Errorf("Applying an empty changeset should be a no-op, got error: %v", err)
+	}
+
+	name := "contract-test." + rrsets.Zone().Name()
+	rrset := rrsets.New(name, []string{"1.2.3.4"}, 180, rrstype.A)
+	changeset := rrsets.StartChangeset().Add(rrset)
+	if changeset.IsEmpty() {
+		t.Errorf("Expected a changeset with a queued Add to report non-empty")
+	}
+	if found, err := rrsets.Get(name); err != nil {
+		t.Fatalf("Failed to get resource record set %s: %v", name, err)
+	} else if len(found) != 0 {
+		t.Errorf("Expected queued record set %s not to be visible before Apply, got %v", name, found)
+	}
+	if err := changeset.Apply(ctx); err != nil {
+		t.Fatalf("Failed to apply changeset: %v", err)
+	}
+	defer rrsets.StartChangeset().Remove(rrset).Apply(ctx)
+
+	found, err := rrsets.Get(name)
+	if err != nil {
+		t.Fatalf("Failed to get resource record set %s: %v", name, err)
+	}
+	if len(found) != 1 {
+		t.Errorf("Expected exactly 1 resource record set named %s, got %d", name, len(found))
+	}
+}